@@ -0,0 +1,66 @@
+package v1alpha4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnumType is a postgres `CREATE TYPE ... AS ENUM (...)` declaration. Values
+// are ordered: this is the order the enum will be created in, and the order
+// used to compute ADD VALUE ... BEFORE/AFTER statements on later applies.
+type EnumType struct {
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values" yaml:"values"`
+}
+
+// SQLTypeSchema is the per-driver body of a DataType spec. Only postgres is
+// supported today; mysql has no equivalent first-class enum type (it models
+// enums as a column attribute, not a named type) so there is nothing to add
+// here for it yet.
+type SQLTypeSchema struct {
+	Postgres *PostgresqlTypeSchema `json:"postgres,omitempty" yaml:"postgres,omitempty"`
+}
+
+// PostgresqlTypeSchema is the postgres-specific body of a DataType spec.
+type PostgresqlTypeSchema struct {
+	Enum *EnumType `json:"enum,omitempty" yaml:"enum,omitempty"`
+}
+
+// DataTypeSpec defines the desired state of a DataType
+type DataTypeSpec struct {
+	Database  string         `json:"database" yaml:"database"`
+	Name      string         `json:"name" yaml:"name"`
+	Schema    *SQLTypeSchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	IsDeleted bool           `json:"isDeleted,omitempty" yaml:"isDeleted,omitempty"`
+}
+
+// DataTypeStatus defines the observed state of a DataType
+type DataTypeStatus struct {
+	LastPlannedDataTypeSpecSHA string `json:"lastPlannedDataTypeSpecSHA,omitempty" yaml:"lastPlannedDataTypeSpecSHA,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// DataType is the Schema for the datatypes API. It manages a single
+// user-defined type (currently: postgres enums) the same way a Table manages
+// a single table, and is applied before any Table that depends on it.
+type DataType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DataTypeSpec   `json:"spec,omitempty"`
+	Status DataTypeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DataTypeList contains a list of DataType
+type DataTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DataType `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DataType{}, &DataTypeList{})
+}
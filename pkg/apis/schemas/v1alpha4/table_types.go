@@ -0,0 +1,98 @@
+package v1alpha4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SQLTableColumnConstraints are the constraints on a single column.
+type SQLTableColumnConstraints struct {
+	NotNull *bool `json:"notNull,omitempty" yaml:"notNull,omitempty"`
+}
+
+// SQLTableColumn is a single column in a SQLTableSchema.
+type SQLTableColumn struct {
+	Name        string                     `json:"name" yaml:"name"`
+	Type        string                     `json:"type" yaml:"type"`
+	Constraints *SQLTableColumnConstraints `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	Default     *string                    `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// SQLTableForeignKeyReferences is the parent side of a foreign key.
+type SQLTableForeignKeyReferences struct {
+	Table   string   `json:"table" yaml:"table"`
+	Columns []string `json:"columns" yaml:"columns"`
+}
+
+// SQLTableForeignKey is a single foreign key in a SQLTableSchema.
+type SQLTableForeignKey struct {
+	Name       string                       `json:"name,omitempty" yaml:"name,omitempty"`
+	Columns    []string                     `json:"columns" yaml:"columns"`
+	References SQLTableForeignKeyReferences `json:"references" yaml:"references"`
+}
+
+// SQLTableIndex is a single index in a SQLTableSchema. Concurrent requests
+// that the index be created with CREATE INDEX CONCURRENTLY so the apply
+// doesn't hold a table-wide lock for the duration of the build. Method names
+// the index access method (btree, gin, gist, brin, hash, ...), defaulting to
+// btree when empty. Predicate, when set, makes this a partial index.
+type SQLTableIndex struct {
+	Name       string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Columns    []string `json:"columns" yaml:"columns"`
+	IsUnique   bool     `json:"isUnique,omitempty" yaml:"isUnique,omitempty"`
+	Concurrent bool     `json:"concurrent,omitempty" yaml:"concurrent,omitempty"`
+	Method     string   `json:"method,omitempty" yaml:"method,omitempty"`
+	Predicate  *string  `json:"predicate,omitempty" yaml:"predicate,omitempty"`
+}
+
+// SQLTableSchema is the postgres-specific body of a Table spec.
+type SQLTableSchema struct {
+	PrimaryKey  []string              `json:"primaryKey,omitempty" yaml:"primaryKey,omitempty"`
+	Columns     []*SQLTableColumn     `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Indexes     []*SQLTableIndex      `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+	ForeignKeys []*SQLTableForeignKey `json:"foreignKeys,omitempty" yaml:"foreignKeys,omitempty"`
+	IsDeleted   bool                  `json:"isDeleted,omitempty" yaml:"isDeleted,omitempty"`
+}
+
+// TableSpec defines the desired state of a Table
+type TableSpec struct {
+	Database string           `json:"database" yaml:"database"`
+	Name     string           `json:"name" yaml:"name"`
+	Schema   *TableDataSchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// TableDataSchema is the per-driver body of a Table spec. Only postgres is
+// declared here; the other drivers' schema types live alongside their own
+// planners.
+type TableDataSchema struct {
+	Postgres *SQLTableSchema `json:"postgres,omitempty" yaml:"postgres,omitempty"`
+}
+
+// TableStatus defines the observed state of a Table
+type TableStatus struct {
+	LastPlannedTableSpecSHA string `json:"lastPlannedTableSpecSHA,omitempty" yaml:"lastPlannedTableSpecSHA,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Table is the Schema for the tables API
+type Table struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TableSpec   `json:"spec,omitempty"`
+	Status TableStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TableList contains a list of Table
+type TableList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Table `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Table{}, &TableList{})
+}
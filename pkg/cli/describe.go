@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/schemahero/schemahero/pkg/database/postgres"
+	"github.com/spf13/cobra"
+)
+
+// DescribeCmd returns the `describe` subcommand, which prints a read-only
+// snapshot of a schema (every table, enum, sequence and view) produced by
+// postgres.ReadSchema. It's the kubectl-schemahero side of the same snapshot
+// PlanPostgresTableFromSchema is built to consume in bulk.
+func DescribeCmd() *cobra.Command {
+	var uri string
+	var schemaName string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Describe the current shape of a schema",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return describe(uri, schemaName, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&uri, "uri", "", "connection uri of the database to describe")
+	cmd.Flags().StringVar(&schemaName, "schema", "public", "schema to describe")
+	cmd.Flags().StringVar(&output, "output", "json", "output format (only json is supported today)")
+
+	return cmd
+}
+
+func describe(uri string, schemaName string, output string) error {
+	if output != "json" {
+		return errors.Errorf("unsupported output format %q", output)
+	}
+
+	schema, err := postgres.ReadSchema(uri, schemaName)
+	if err != nil {
+		return errors.Wrap(err, "failed to read schema")
+	}
+
+	encoded, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal schema")
+	}
+
+	fmt.Println(string(encoded))
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	schemasv1alpha4 "github.com/schemahero/schemahero/pkg/apis/schemas/v1alpha4"
+	"github.com/schemahero/schemahero/pkg/database/postgres"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// PlanCmd returns the `plan` subcommand: it plans tableName's forward
+// statements, and with --emit-down, also the rollback statements
+// PlanPostgresTableWithReverse derives for whichever of them can be
+// losslessly undone.
+func PlanCmd() *cobra.Command {
+	var uri string
+	var tableName string
+	var specFile string
+	var emitDown bool
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Plan the statements needed to converge a table to its spec",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return plan(uri, tableName, specFile, emitDown)
+		},
+	}
+
+	cmd.Flags().StringVar(&uri, "uri", "", "connection uri of the database to plan against")
+	cmd.Flags().StringVar(&tableName, "table", "", "name of the table to plan")
+	cmd.Flags().StringVar(&specFile, "spec", "", "path to the table's SQLTableSchema, as yaml")
+	cmd.Flags().BoolVar(&emitDown, "emit-down", false, "also print the rollback statements for any reversible change")
+
+	return cmd
+}
+
+func plan(uri string, tableName string, specFile string, emitDown bool) error {
+	tableSchema, err := readTableSchema(specFile)
+	if err != nil {
+		return err
+	}
+
+	if !emitDown {
+		statements, err := postgres.PlanPostgresTable(uri, tableName, tableSchema)
+		if err != nil {
+			return errors.Wrap(err, "failed to plan table")
+		}
+		for _, statement := range statements {
+			fmt.Println(statement)
+		}
+		return nil
+	}
+
+	forward, reverse, err := postgres.PlanPostgresTableWithReverse(uri, tableName, tableSchema)
+	if err != nil {
+		return errors.Wrap(err, "failed to plan table")
+	}
+
+	encoded, err := json.MarshalIndent(struct {
+		Forward []postgres.Statement `json:"forward"`
+		Reverse []postgres.Statement `json:"reverse"`
+	}{forward, reverse}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal plan")
+	}
+
+	fmt.Println(string(encoded))
+	return nil
+}
+
+func readTableSchema(specFile string) (*schemasv1alpha4.SQLTableSchema, error) {
+	contents, err := os.ReadFile(specFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read spec file")
+	}
+
+	tableSchema := &schemasv1alpha4.SQLTableSchema{}
+	if err := yaml.Unmarshal(contents, tableSchema); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal spec")
+	}
+
+	return tableSchema, nil
+}
@@ -2,7 +2,6 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 
 	"github.com/lib/pq"
@@ -11,6 +10,13 @@ import (
 	"github.com/schemahero/schemahero/pkg/database/types"
 )
 
+// PlanPostgresTable plans the statements needed to bring tableName to the
+// shape described by postgresTableSchema, introspecting just that one table.
+// A caller already holding a schema-wide snapshot from ReadSchema (for
+// example a controller reconciling every Table CR in a namespace in one
+// pass) should use PlanPostgresTableFromSchema instead, so planning N tables
+// reads columns, keys and indexes once for the whole schema rather than once
+// per table.
 func PlanPostgresTable(uri string, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
 	p, err := Connect(uri)
 	if err != nil {
@@ -18,6 +24,25 @@ func PlanPostgresTable(uri string, tableName string, postgresTableSchema *schema
 	}
 	defer p.db.Close()
 
+	return planPostgresTable(p, tableName, postgresTableSchema, nil)
+}
+
+// PlanPostgresTableFromSchema plans tableName the same way PlanPostgresTable
+// does, but takes its current columns, keys and indexes from schema (as
+// produced by ReadSchema) instead of introspecting them on its own. Whether
+// the table exists, and its constraint names, are still read from the live
+// database.
+func PlanPostgresTableFromSchema(uri string, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema, schema *types.Schema) ([]string, error) {
+	p, err := Connect(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to postgres")
+	}
+	defer p.db.Close()
+
+	return planPostgresTable(p, tableName, postgresTableSchema, schema)
+}
+
+func planPostgresTable(p *PostgresConnection, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema, schema *types.Schema) ([]string, error) {
 	// determine if the table exists
 	query := `select count(1) from information_schema.tables where table_name = $1`
 	row := p.db.QueryRow(query, tableName)
@@ -44,31 +69,42 @@ func PlanPostgresTable(uri string, tableName string, postgresTableSchema *schema
 		return []string{query}, nil
 	}
 
+	var graph *schemaGraph
+	if schema != nil {
+		graph = schemaGraphForTable(schema, tableName)
+	} else {
+		var err error
+		graph, err = introspectTable(p.db, tableName)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to introspect table")
+		}
+	}
+
 	statements := []string{}
 
 	// table needs to be altered?
-	columnStatements, err := buildColumnStatements(p, tableName, postgresTableSchema)
+	columnStatements, err := buildColumnStatements(tableName, graph, postgresTableSchema)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build column statement")
 	}
 	statements = append(statements, columnStatements...)
 
 	// primary key changes
-	primaryKeyStatements, err := buildPrimaryKeyStatements(p, tableName, postgresTableSchema)
+	primaryKeyStatements, err := buildPrimaryKeyStatements(tableName, graph, postgresTableSchema)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build primary key statements")
 	}
 	statements = append(statements, primaryKeyStatements...)
 
 	// foreign key changes
-	foreignKeyStatements, err := buildForeignKeyStatements(p, tableName, postgresTableSchema)
+	foreignKeyStatements, err := buildForeignKeyStatements(tableName, graph, postgresTableSchema)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build foreign key statements")
 	}
 	statements = append(statements, foreignKeyStatements...)
 
 	// index changes
-	indexStatements, err := buildIndexStatements(p, tableName, postgresTableSchema)
+	indexStatements, err := buildIndexStatements(p.db, tableName, graph, postgresTableSchema)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build index statements")
 	}
@@ -106,55 +142,19 @@ func executeStatements(p *PostgresConnection, statements []string) error {
 	return nil
 }
 
-func buildColumnStatements(p *PostgresConnection, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
-	query := `select
-column_name, column_default, is_nullable, data_type, udt_name, character_maximum_length
-from information_schema.columns
-where table_name = $1`
-	rows, err := p.db.Query(query, tableName)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to select from information_schema")
-	}
-
+func buildColumnStatements(tableName string, graph *schemaGraph, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
 	alterAndDropStatements := []string{}
 	foundColumnNames := []string{}
-	for rows.Next() {
-		var columnName, dataType, udtName, isNullable string
-		var columnDefault sql.NullString
-		var charMaxLength sql.NullInt64
-
-		if err := rows.Scan(&columnName, &columnDefault, &isNullable, &dataType, &udtName, &charMaxLength); err != nil {
-			return nil, errors.Wrap(err, "failed to scan")
-		}
-
-		foundColumnNames = append(foundColumnNames, columnName)
-
-		existingColumn := types.Column{
-			Name:        columnName,
-			DataType:    dataType,
-			Constraints: &types.ColumnConstraints{},
-		}
-
-		if dataType == "ARRAY" {
-			existingColumn.IsArray = true
-			existingColumn.DataType = UDTNameToDataType(udtName)
-		}
-
-		if isNullable == "NO" {
-			existingColumn.Constraints.NotNull = &trueValue
-		} else {
-			existingColumn.Constraints.NotNull = &falseValue
-		}
+	for _, existingColumn := range graph.Columns {
+		foundColumnNames = append(foundColumnNames, existingColumn.Name)
 
-		if columnDefault.Valid {
-			value := stripOIDClass(columnDefault.String)
-			existingColumn.ColumnDefault = &value
-		}
-		if charMaxLength.Valid {
-			existingColumn.DataType = fmt.Sprintf("%s (%d)", existingColumn.DataType, charMaxLength.Int64)
+		if existingColumn.EnumName != nil {
+			if err := validateEnumColumn(tableName, existingColumn, postgresTableSchema.Columns); err != nil {
+				return nil, err
+			}
 		}
 
-		columnStatement, err := AlterColumnStatements(tableName, postgresTableSchema.PrimaryKey, postgresTableSchema.Columns, &existingColumn)
+		columnStatement, err := AlterColumnStatements(tableName, postgresTableSchema.PrimaryKey, postgresTableSchema.Columns, existingColumn)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create alter column statement")
 		}
@@ -183,11 +183,8 @@ where table_name = $1`
 	return alterAndDropStatements, nil
 }
 
-func buildPrimaryKeyStatements(p *PostgresConnection, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
-	currentPrimaryKey, err := p.GetTablePrimaryKey(tableName)
-	if err != nil {
-		return nil, err
-	}
+func buildPrimaryKeyStatements(tableName string, graph *schemaGraph, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
+	currentPrimaryKey := graph.PrimaryKey
 	var postgresTableSchemaPrimaryKey *types.KeyConstraint
 	if len(postgresTableSchema.PrimaryKey) > 0 {
 		postgresTableSchemaPrimaryKey = &types.KeyConstraint{
@@ -212,13 +209,10 @@ func buildPrimaryKeyStatements(p *PostgresConnection, tableName string, postgres
 	return statements, nil
 }
 
-func buildForeignKeyStatements(p *PostgresConnection, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
+func buildForeignKeyStatements(tableName string, graph *schemaGraph, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
 	foreignKeyStatements := []string{}
 	droppedKeys := []string{}
-	currentForeignKeys, err := p.ListTableForeignKeys(p.databaseName, tableName)
-	if err != nil {
-		return nil, err
-	}
+	currentForeignKeys := graph.ForeignKeys
 
 	for _, foreignKey := range postgresTableSchema.ForeignKeys {
 		var statement string
@@ -268,14 +262,11 @@ func buildForeignKeyStatements(p *PostgresConnection, tableName string, postgres
 	return foreignKeyStatements, nil
 }
 
-func buildIndexStatements(p *PostgresConnection, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
+func buildIndexStatements(db sqlQuerier, tableName string, graph *schemaGraph, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]string, error) {
 	indexStatements := []string{}
 	droppedIndexes := []string{}
-	currentIndexes, err := p.ListTableIndexes(p.databaseName, tableName)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to list table indexes")
-	}
-	currentConstraints, err := p.ListTableConstraints(p.databaseName, tableName)
+	currentIndexes := graph.Indexes
+	currentConstraints, err := introspectConstraintNames(db, tableName)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list table constraints")
 	}
@@ -316,6 +307,9 @@ func buildIndexStatements(p *PostgresConnection, tableName string, postgresTable
 		}
 
 		statement = AddIndexStatement(tableName, index)
+		if index.Concurrent {
+			statement = rewriteIndexStatementConcurrently(statement)
+		}
 		indexStatements = append(indexStatements, statement)
 
 	Next:
@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	schemasv1alpha4 "github.com/schemahero/schemahero/pkg/apis/schemas/v1alpha4"
+)
+
+// PreflightCheck re-runs the planner against the live database immediately
+// before a deploy executes, inside the same transaction, and aborts the
+// deploy if the statements it would now produce differ from the ones it was
+// asked to apply. This guards against a second operator racing a change into
+// the table between plan time and apply time.
+type PreflightCheck struct {
+	TableName         string
+	TableSchema       *schemasv1alpha4.SQLTableSchema
+	PlannedStatements []string
+}
+
+// DeployOptions configures DeployPostgresStatementsWithOptions. The zero
+// value sets no timeouts and runs no preflight check; unlike plain
+// DeployPostgresStatements, statements are still wrapped in a transaction
+// whenever that's safe.
+type DeployOptions struct {
+	LockTimeout      time.Duration
+	StatementTimeout time.Duration
+	Preflight        *PreflightCheck
+}
+
+// DeployPostgresStatementsWithOptions is DeployPostgresStatements plus the
+// safety behavior a production apply needs: statements that postgres allows
+// inside a transaction are batched into a single BEGIN/COMMIT so a failure
+// partway through never leaves the table half migrated; statements that
+// postgres refuses to run in a transaction (CREATE INDEX CONCURRENTLY,
+// VACUUM, REINDEX CONCURRENTLY, and ALTER TYPE ... ADD VALUE on older
+// servers) run individually, outside it, after the transaction commits.
+// Every ALTER TYPE statement is routed outside the transaction, whatever the
+// server version. lock_timeout and statement_timeout, when set, are applied
+// with SET LOCAL inside the transaction, so they cover only the
+// transactional statements; the non-transactional ones run without them.
+func DeployPostgresStatementsWithOptions(uri string, statements []string, opts DeployOptions) error {
+	p, err := Connect(uri)
+	if err != nil {
+		return err
+	}
+	defer p.db.Close()
+
+	transactional, nonTransactional := splitTransactionSafety(statements)
+
+	if len(transactional) > 0 {
+		if err := deployTransactional(p, transactional, opts); err != nil {
+			return err
+		}
+	}
+
+	if len(nonTransactional) > 0 {
+		if err := executeStatements(p, nonTransactional); err != nil {
+			return errors.Wrap(err, "failed to execute non-transactional statements")
+		}
+	}
+
+	return nil
+}
+
+func deployTransactional(p *PostgresConnection, statements []string, opts DeployOptions) error {
+	tx, err := p.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	if opts.LockTimeout > 0 {
+		if _, err := tx.ExecContext(context.Background(), fmt.Sprintf("set local lock_timeout = %d", opts.LockTimeout.Milliseconds())); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "failed to set lock_timeout")
+		}
+	}
+
+	if opts.StatementTimeout > 0 {
+		if _, err := tx.ExecContext(context.Background(), fmt.Sprintf("set local statement_timeout = %d", opts.StatementTimeout.Milliseconds())); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "failed to set statement_timeout")
+		}
+	}
+
+	if opts.Preflight != nil {
+		if err := runPreflightCheck(tx, opts.Preflight); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for _, statement := range statements {
+		if statement == "" {
+			continue
+		}
+		fmt.Printf("Executing query %q\n", statement)
+		if _, err := tx.ExecContext(context.Background(), statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	return nil
+}
+
+// runPreflightCheck replans the table and compares the result to the
+// statements the caller planned earlier. It runs against tx, the same
+// transaction the apply will execute in, so the replan actually observes
+// lock_timeout/statement_timeout and runs in the same session as the
+// statements that follow it — a plain *sql.DB query here would run on a
+// separate session and the race window this check exists to close would
+// stay open. Anything other than an exact match means the table has moved
+// since planning, so the deploy is aborted rather than applying statements
+// against a shape they were never diffed against.
+func runPreflightCheck(tx *sql.Tx, preflight *PreflightCheck) error {
+	graph, err := introspectTable(tx, preflight.TableName)
+	if err != nil {
+		return errors.Wrap(err, "failed to introspect table for preflight check")
+	}
+
+	columnStatements, err := buildColumnStatements(preflight.TableName, graph, preflight.TableSchema)
+	if err != nil {
+		return errors.Wrap(err, "failed to build column statements for preflight check")
+	}
+	primaryKeyStatements, err := buildPrimaryKeyStatements(preflight.TableName, graph, preflight.TableSchema)
+	if err != nil {
+		return errors.Wrap(err, "failed to build primary key statements for preflight check")
+	}
+	foreignKeyStatements, err := buildForeignKeyStatements(preflight.TableName, graph, preflight.TableSchema)
+	if err != nil {
+		return errors.Wrap(err, "failed to build foreign key statements for preflight check")
+	}
+	indexStatements, err := buildIndexStatements(tx, preflight.TableName, graph, preflight.TableSchema)
+	if err != nil {
+		return errors.Wrap(err, "failed to build index statements for preflight check")
+	}
+
+	current := append(append(append(columnStatements, primaryKeyStatements...), foreignKeyStatements...), indexStatements...)
+
+	if !statementsEqual(current, preflight.PlannedStatements) {
+		return errors.Errorf("table %q has changed since this plan was generated, refusing to apply a stale migration", preflight.TableName)
+	}
+
+	return nil
+}
+
+func statementsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// transactionUnsafeMarkers are substrings of statements that postgres
+// refuses to run inside a transaction block. "index concurrently" (rather
+// than "create index concurrently") so it also matches "create unique index
+// concurrently ...", where "unique" sits between "create" and "index" - and
+// "reindex concurrently", since "reindex" itself ends in "index".
+var transactionUnsafeMarkers = []string{
+	"index concurrently",
+	"vacuum",
+	"alter type",
+}
+
+func splitTransactionSafety(statements []string) (transactional []string, nonTransactional []string) {
+	for _, statement := range statements {
+		if isTransactionUnsafe(statement) {
+			nonTransactional = append(nonTransactional, statement)
+		} else {
+			transactional = append(transactional, statement)
+		}
+	}
+	return transactional, nonTransactional
+}
+
+func isTransactionUnsafe(statement string) bool {
+	lower := strings.ToLower(statement)
+	for _, marker := range transactionUnsafeMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// createIndexPrefixRegexp matches the `create index ` or `create unique
+// index ` a statement opens with, the same optional-unique shape
+// createIndexRegexp (reverse.go) matches when it later parses the index
+// name back out of this same statement.
+var createIndexPrefixRegexp = regexp.MustCompile(`(?i)^create(?:\s+unique)?\s+index\s+`)
+
+// rewriteIndexStatementConcurrently rewrites a `create index` or `create
+// unique index` statement to insert `concurrently` right after `index`, so
+// buildIndexStatements can honor an Index's `concurrent: true` flag for
+// unique indexes too, not just plain ones. CREATE INDEX CONCURRENTLY can't
+// run inside a transaction, so statements it touches are routed to the
+// non-transactional batch by splitTransactionSafety above.
+func rewriteIndexStatementConcurrently(statement string) string {
+	loc := createIndexPrefixRegexp.FindStringIndex(statement)
+	if loc == nil {
+		return statement
+	}
+
+	return statement[:loc[1]] + "concurrently " + statement[loc[1]:]
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rewriteIndexStatementConcurrently(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		expected  string
+	}{
+		{
+			name:      "plain index",
+			statement: `create index "idx_foo" on "t" ("c")`,
+			expected:  `create index concurrently "idx_foo" on "t" ("c")`,
+		},
+		{
+			name:      "unique index",
+			statement: `create unique index "idx_foo" on "t" ("c")`,
+			expected:  `create unique index concurrently "idx_foo" on "t" ("c")`,
+		},
+		{
+			name:      "not an index statement",
+			statement: `alter table "t" add column "c" integer`,
+			expected:  `alter table "t" add column "c" integer`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, rewriteIndexStatementConcurrently(test.statement))
+		})
+	}
+}
+
+func Test_isTransactionUnsafe(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		expected  bool
+	}{
+		{
+			name:      "create index concurrently",
+			statement: `create index concurrently "idx_foo" on "t" ("c")`,
+			expected:  true,
+		},
+		{
+			name:      "unique create index concurrently",
+			statement: `create unique index concurrently "idx_foo" on "t" ("c")`,
+			expected:  true,
+		},
+		{
+			name:      "vacuum",
+			statement: `vacuum "t"`,
+			expected:  true,
+		},
+		{
+			name:      "alter type",
+			statement: `alter type "status" add value 'c' after 'b'`,
+			expected:  true,
+		},
+		{
+			name:      "plain add column is safe",
+			statement: `alter table "t" add column "c" integer`,
+			expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isTransactionUnsafe(test.statement))
+		})
+	}
+}
+
+func Test_splitTransactionSafety(t *testing.T) {
+	statements := []string{
+		`alter table "t" add column "c" integer`,
+		`create unique index concurrently "idx_foo" on "t" ("c")`,
+		`alter table "t" drop column "d"`,
+	}
+
+	transactional, nonTransactional := splitTransactionSafety(statements)
+
+	assert.Equal(t, []string{
+		`alter table "t" add column "c" integer`,
+		`alter table "t" drop column "d"`,
+	}, transactional)
+	assert.Equal(t, []string{
+		`create unique index concurrently "idx_foo" on "t" ("c")`,
+	}, nonTransactional)
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	schemasv1alpha4 "github.com/schemahero/schemahero/pkg/apis/schemas/v1alpha4"
+	"github.com/schemahero/schemahero/pkg/database/types"
+)
+
+// PlanPostgresType plans the statements needed to bring typeName to the shape
+// described by dataTypeSpec. Only enum types are supported so far.
+//
+// New values are added with ALTER TYPE ... ADD VALUE ... BEFORE/AFTER so that
+// the existing order is preserved and the new values land where the spec put
+// them. A value that is present in the database but missing from the spec is
+// refused: postgres cannot drop a single enum value without rewriting every
+// table that uses the type, so removing one is treated as a destructive,
+// manual operation rather than something schemahero will do silently.
+func PlanPostgresType(uri string, typeName string, dataTypeSpec *schemasv1alpha4.DataTypeSpec) ([]string, error) {
+	p, err := Connect(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to postgres")
+	}
+	defer p.db.Close()
+
+	currentValues, exists, err := enumValues(p, typeName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read current enum values")
+	}
+
+	if dataTypeSpec.IsDeleted {
+		if !exists {
+			return []string{}, nil
+		}
+		return []string{fmt.Sprintf(`drop type %s`, pq.QuoteIdentifier(typeName))}, nil
+	}
+
+	if dataTypeSpec.Schema == nil || dataTypeSpec.Schema.Postgres == nil || dataTypeSpec.Schema.Postgres.Enum == nil {
+		return nil, errors.New("only enum types are supported")
+	}
+	postgresTypeSchema := dataTypeSpec.Schema.Postgres
+
+	desiredValues := postgresTypeSchema.Enum.Values
+
+	if !exists {
+		return []string{createEnumTypeStatement(typeName, desiredValues)}, nil
+	}
+
+	return diffEnumValues(typeName, currentValues, desiredValues)
+}
+
+func enumValues(p *PostgresConnection, typeName string) ([]string, bool, error) {
+	query := `select e.enumlabel
+from pg_type t
+join pg_enum e on e.enumtypid = t.oid
+where t.typname = $1
+order by e.enumsortorder`
+
+	rows, err := p.db.Query(query, typeName)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, false, err
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	return values, len(values) > 0, nil
+}
+
+func createEnumTypeStatement(typeName string, values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, pq.QuoteLiteral(value))
+	}
+
+	return fmt.Sprintf(`create type %s as enum (%s)`, pq.QuoteIdentifier(typeName), joinComma(quoted))
+}
+
+// diffEnumValues compares the current, on-disk enum ordering to the desired
+// one and fails closed the moment a value has been removed or a retained
+// value has been reordered, rather than attempting to guess a migration for
+// it. Postgres can only insert a new enum label before or after an existing
+// one; it cannot move a label that's already there, so a spec that asks for
+// a different relative order among retained values is not something ADD
+// VALUE can ever produce.
+func diffEnumValues(typeName string, currentValues, desiredValues []string) ([]string, error) {
+	desiredSet := map[string]bool{}
+	for _, value := range desiredValues {
+		desiredSet[value] = true
+	}
+
+	for _, value := range currentValues {
+		if !desiredSet[value] {
+			return nil, errors.Errorf("enum value %q was removed from type %q: postgres cannot drop an enum value without rewriting every table that uses it, this requires a manual migration", value, typeName)
+		}
+	}
+
+	currentSet := map[string]bool{}
+	for _, value := range currentValues {
+		currentSet[value] = true
+	}
+
+	retainedDesired := make([]string, 0, len(currentValues))
+	for _, value := range desiredValues {
+		if currentSet[value] {
+			retainedDesired = append(retainedDesired, value)
+		}
+	}
+	for i, value := range currentValues {
+		if retainedDesired[i] != value {
+			return nil, errors.Errorf("enum type %q has retained values in a different order than the spec declares: postgres cannot reorder an existing enum value, only insert a new one before or after an existing one, this requires a manual migration", typeName)
+		}
+	}
+
+	statements := []string{}
+	previous := ""
+	for _, value := range desiredValues {
+		if currentSet[value] {
+			previous = value
+			continue
+		}
+
+		if previous == "" {
+			statements = append(statements, fmt.Sprintf(`alter type %s add value %s before %s`,
+				pq.QuoteIdentifier(typeName), pq.QuoteLiteral(value), pq.QuoteLiteral(firstOf(desiredValues, currentSet))))
+		} else {
+			statements = append(statements, fmt.Sprintf(`alter type %s add value %s after %s`,
+				pq.QuoteIdentifier(typeName), pq.QuoteLiteral(value), pq.QuoteLiteral(previous)))
+		}
+
+		previous = value
+	}
+
+	return statements, nil
+}
+
+// firstOf returns the first value in values that is already present in
+// existing, used to anchor a BEFORE clause when new values are prepended.
+func firstOf(values []string, existing map[string]bool) string {
+	for _, value := range values {
+		if existing[value] {
+			return value
+		}
+	}
+	return ""
+}
+
+// validateEnumColumn fails closed when a column backed by a postgres enum
+// type is declared in the spec with a different type name: that can only
+// mean the DataType itself needs to change first (and, if it's a value
+// removal, can't be done automatically at all), not something
+// AlterColumnStatements can paper over with an ALTER COLUMN TYPE.
+func validateEnumColumn(tableName string, existingColumn *types.Column, desiredColumns []*schemasv1alpha4.SQLTableColumn) error {
+	for _, desiredColumn := range desiredColumns {
+		if desiredColumn.Name != existingColumn.Name {
+			continue
+		}
+
+		if desiredColumn.Type != *existingColumn.EnumName {
+			return errors.Errorf("column %q.%q is backed by enum type %q but the spec declares type %q: apply the DataType change first",
+				tableName, existingColumn.Name, *existingColumn.EnumName, desiredColumn.Type)
+		}
+	}
+
+	return nil
+}
+
+func joinComma(values []string) string {
+	result := ""
+	for i, value := range values {
+		if i > 0 {
+			result += ", "
+		}
+		result += value
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_diffEnumValues(t *testing.T) {
+	tests := []struct {
+		name           string
+		typeName       string
+		currentValues  []string
+		desiredValues  []string
+		expected       []string
+		expectedErrMsg string
+	}{
+		{
+			name:          "no changes",
+			typeName:      "status",
+			currentValues: []string{"a", "b"},
+			desiredValues: []string{"a", "b"},
+			expected:      []string{},
+		},
+		{
+			name:          "append a value",
+			typeName:      "status",
+			currentValues: []string{"a", "b"},
+			desiredValues: []string{"a", "b", "c"},
+			expected:      []string{`alter type "status" add value 'c' after 'b'`},
+		},
+		{
+			name:          "prepend a value",
+			typeName:      "status",
+			currentValues: []string{"a", "b"},
+			desiredValues: []string{"x", "a", "b"},
+			expected:      []string{`alter type "status" add value 'x' before 'a'`},
+		},
+		{
+			name:          "insert a value in the middle",
+			typeName:      "status",
+			currentValues: []string{"a", "b"},
+			desiredValues: []string{"a", "x", "b"},
+			expected:      []string{`alter type "status" add value 'x' after 'a'`},
+		},
+		{
+			name:           "removing a value fails closed",
+			typeName:       "status",
+			currentValues:  []string{"a", "b"},
+			desiredValues:  []string{"a"},
+			expectedErrMsg: `enum value "b" was removed from type "status"`,
+		},
+		{
+			name:           "reordering retained values fails closed",
+			typeName:       "status",
+			currentValues:  []string{"a", "b"},
+			desiredValues:  []string{"b", "a"},
+			expectedErrMsg: `enum type "status" has retained values in a different order`,
+		},
+		{
+			name:           "reordering retained values around a new value fails closed",
+			typeName:       "status",
+			currentValues:  []string{"a", "b"},
+			desiredValues:  []string{"b", "x", "a"},
+			expectedErrMsg: `enum type "status" has retained values in a different order`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := diffEnumValues(test.typeName, test.currentValues, test.desiredValues)
+			if test.expectedErrMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_firstOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		existing map[string]bool
+		expected string
+	}{
+		{
+			name:     "first value present",
+			values:   []string{"a", "b", "c"},
+			existing: map[string]bool{"a": true, "c": true},
+			expected: "a",
+		},
+		{
+			name:     "first present value is not the first value",
+			values:   []string{"a", "b", "c"},
+			existing: map[string]bool{"b": true},
+			expected: "b",
+		},
+		{
+			name:     "no value present",
+			values:   []string{"a", "b"},
+			existing: map[string]bool{},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, firstOf(test.values, test.existing))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package fuzz
+
+import (
+	"fmt"
+	"math/rand"
+
+	schemasv1alpha4 "github.com/schemahero/schemahero/pkg/apis/schemas/v1alpha4"
+)
+
+// columnTypes are the postgres types the generator is allowed to pick from.
+// Array variants are added separately, for about one generated column in
+// four, which is enough to exercise IsArray handling without
+// every generated table being dominated by them.
+var columnTypes = []string{
+	"integer",
+	"bigint",
+	"text",
+	"varchar (255)",
+	"boolean",
+	"timestamp",
+	"numeric (10, 2)",
+}
+
+// GenerateTableSchema produces a random-but-valid SQLTableSchema for
+// tableName. parents is the set of already-generated table names this table
+// is allowed to reference with a foreign key; passing an empty slice
+// guarantees a table with no foreign keys, which is what the first table in
+// a fuzz run should get.
+func GenerateTableSchema(r *rand.Rand, tableName string, parents []*schemasv1alpha4.SQLTableSchema) *schemasv1alpha4.SQLTableSchema {
+	numColumns := 2 + r.Intn(6)
+
+	columns := []*schemasv1alpha4.SQLTableColumn{
+		{Name: "id", Type: "integer"},
+	}
+	for i := 0; i < numColumns; i++ {
+		columns = append(columns, generateColumn(r, fmt.Sprintf("col_%d", i)))
+	}
+
+	schema := &schemasv1alpha4.SQLTableSchema{
+		PrimaryKey: []string{"id"},
+		Columns:    columns,
+		Indexes:    generateIndexes(r, columns),
+	}
+
+	if len(parents) > 0 && r.Intn(2) == 0 {
+		parent := parents[r.Intn(len(parents))]
+		fkColumn := generateColumn(r, "parent_id")
+		fkColumn.Type = "integer"
+		schema.Columns = append(schema.Columns, fkColumn)
+		schema.ForeignKeys = append(schema.ForeignKeys, &schemasv1alpha4.SQLTableForeignKey{
+			Columns:    []string{fkColumn.Name},
+			References: schemasv1alpha4.SQLTableForeignKeyReferences{Table: parent.Name, Columns: []string{"id"}},
+		})
+	}
+
+	return schema
+}
+
+func generateColumn(r *rand.Rand, name string) *schemasv1alpha4.SQLTableColumn {
+	column := &schemasv1alpha4.SQLTableColumn{
+		Name: name,
+		Type: columnTypes[r.Intn(len(columnTypes))],
+	}
+
+	if r.Intn(4) == 0 {
+		column.Type += "[]"
+	}
+
+	if r.Intn(3) == 0 {
+		notNull := true
+		column.Constraints = &schemasv1alpha4.SQLTableColumnConstraints{NotNull: &notNull}
+	}
+
+	return column
+}
+
+// generateIndexes builds zero or more indexes over the generated columns,
+// including the occasional unique index and partial index, to exercise the
+// paths buildIndexStatements takes beyond a plain btree.
+func generateIndexes(r *rand.Rand, columns []*schemasv1alpha4.SQLTableColumn) []*schemasv1alpha4.SQLTableIndex {
+	if len(columns) < 2 || r.Intn(2) == 0 {
+		return nil
+	}
+
+	indexes := []*schemasv1alpha4.SQLTableIndex{}
+	numIndexes := 1 + r.Intn(2)
+	for i := 0; i < numIndexes && i < len(columns); i++ {
+		index := &schemasv1alpha4.SQLTableIndex{
+			Columns:  []string{columns[i].Name},
+			IsUnique: r.Intn(2) == 0,
+		}
+
+		if r.Intn(3) == 0 {
+			predicate := fmt.Sprintf("%s is not null", columns[i].Name)
+			index.Predicate = &predicate
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes
+}
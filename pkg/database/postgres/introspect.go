@@ -0,0 +1,338 @@
+package postgres
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/schemahero/schemahero/pkg/database/types"
+)
+
+// defaultSchemaName is the schema PlanPostgresTable and ReadSchema assume
+// when a caller doesn't otherwise scope the search, matching postgres' own
+// default search_path.
+const defaultSchemaName = "public"
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx, so introspection can
+// run against a plain connection or inside an in-flight transaction (see
+// runPreflightCheck in deploy_options.go).
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// schemaGraph is the introspected shape of a single table: columns, primary
+// key, foreign keys and indexes, gathered from pg_catalog instead of
+// information_schema so deferrability, partial index predicates, index
+// method, and per-action FK behavior aren't lost. Expression index keys are
+// not captured: an index's Columns lists only its plain table columns.
+type schemaGraph struct {
+	Columns     []*types.Column
+	PrimaryKey  *types.KeyConstraint
+	ForeignKeys []*types.ForeignKey
+	Indexes     []*types.Index
+}
+
+func introspectTable(db sqlQuerier, tableName string) (*schemaGraph, error) {
+	columns, err := introspectColumns(db, tableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect columns")
+	}
+
+	primaryKey, err := introspectPrimaryKey(db, tableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect primary key")
+	}
+
+	foreignKeys, err := introspectForeignKeys(db, tableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect foreign keys")
+	}
+
+	indexes, err := introspectIndexes(db, tableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect indexes")
+	}
+
+	return &schemaGraph{
+		Columns:     columns,
+		PrimaryKey:  primaryKey,
+		ForeignKeys: foreignKeys,
+		Indexes:     indexes,
+	}, nil
+}
+
+func introspectColumns(db sqlQuerier, tableName string) ([]*types.Column, error) {
+	query := `select
+  a.attname,
+  pg_catalog.format_type(a.atttypid, a.atttypmod) as data_type,
+  a.attnotnull,
+  pg_get_expr(ad.adbin, ad.adrelid) as column_default,
+  nullif(a.attidentity, '') as identity,
+  a.attgenerated = 's' as is_generated,
+  t.typtype = 'e' as is_enum,
+  t.typname as udt_name,
+  col_description(a.attrelid, a.attnum) as comment,
+  exists (
+    select 1 from pg_index ix
+    where ix.indrelid = a.attrelid
+      and ix.indisunique
+      and ix.indkey = (a.attnum::text::int2vector)
+  ) as is_unique
+from pg_attribute a
+join pg_class c on c.oid = a.attrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_type t on t.oid = a.atttypid
+left join pg_attrdef ad on ad.adrelid = a.attrelid and ad.adnum = a.attnum
+where c.relname = $1
+  and n.nspname = $2
+  and a.attnum > 0
+  and not a.attisdropped
+order by a.attnum`
+
+	rows, err := db.Query(query, tableName, defaultSchemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for columns")
+	}
+	defer rows.Close()
+
+	columns := []*types.Column{}
+	for rows.Next() {
+		var columnName, dataType, udtName string
+		var notNull, isGenerated, isEnum, isUnique bool
+		var columnDefault, identity, comment sql.NullString
+
+		if err := rows.Scan(&columnName, &dataType, &notNull, &columnDefault, &identity, &isGenerated, &isEnum, &udtName, &comment, &isUnique); err != nil {
+			return nil, errors.Wrap(err, "failed to scan column row")
+		}
+
+		column := &types.Column{
+			Name:        columnName,
+			DataType:    dataType,
+			Constraints: &types.ColumnConstraints{},
+		}
+
+		if strings.HasSuffix(column.DataType, "[]") {
+			column.IsArray = true
+			column.DataType = strings.TrimSuffix(column.DataType, "[]")
+		}
+
+		if notNull {
+			column.Constraints.NotNull = &trueValue
+		} else {
+			column.Constraints.NotNull = &falseValue
+		}
+
+		if columnDefault.Valid {
+			value := stripOIDClass(columnDefault.String)
+			column.ColumnDefault = &value
+		}
+
+		if identity.Valid {
+			column.Identity = identity.String
+		}
+		column.GeneratedAlways = isGenerated
+
+		if isEnum {
+			enumName := udtName
+			column.EnumName = &enumName
+		}
+
+		if comment.Valid {
+			column.Comment = &comment.String
+		}
+		column.Unique = isUnique
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+func introspectPrimaryKey(db sqlQuerier, tableName string) (*types.KeyConstraint, error) {
+	query := `select
+  a.attname
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_attribute a on a.attrelid = con.conrelid and a.attnum = any(con.conkey)
+where c.relname = $1
+  and n.nspname = $2
+  and con.contype = 'p'
+order by array_position(con.conkey, a.attnum)`
+
+	rows, err := db.Query(query, tableName, defaultSchemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for primary key")
+	}
+	defer rows.Close()
+
+	columns := []string{}
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, errors.Wrap(err, "failed to scan primary key column")
+		}
+		columns = append(columns, columnName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	return &types.KeyConstraint{
+		IsPrimary: true,
+		Columns:   columns,
+	}, nil
+}
+
+func introspectForeignKeys(db sqlQuerier, tableName string) ([]*types.ForeignKey, error) {
+	query := `select
+  con.conname,
+  pg_get_constraintdef(con.oid) as definition,
+  array(select a.attname from pg_attribute a where a.attrelid = con.conrelid and a.attnum = any(con.conkey) order by array_position(con.conkey, a.attnum)) as child_columns,
+  fc.relname as parent_table,
+  array(select a.attname from pg_attribute a where a.attrelid = con.confrelid and a.attnum = any(con.confkey) order by array_position(con.confkey, a.attnum)) as parent_columns,
+  con.confupdtype,
+  con.confdeltype,
+  con.condeferrable
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_class fc on fc.oid = con.confrelid
+where c.relname = $1
+  and n.nspname = $2
+  and con.contype = 'f'`
+
+	rows, err := db.Query(query, tableName, defaultSchemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for foreign keys")
+	}
+	defer rows.Close()
+
+	foreignKeys := []*types.ForeignKey{}
+	for rows.Next() {
+		var name, definition, parentTable string
+		var childColumns, parentColumns pq.StringArray
+		var confUpdType, confDelType string
+		var deferrable bool
+
+		if err := rows.Scan(&name, &definition, &childColumns, &parentTable, &parentColumns, &confUpdType, &confDelType, &deferrable); err != nil {
+			return nil, errors.Wrap(err, "failed to scan foreign key row")
+		}
+
+		foreignKeys = append(foreignKeys, &types.ForeignKey{
+			Name:          name,
+			ChildColumns:  []string(childColumns),
+			ParentTable:   parentTable,
+			ParentColumns: []string(parentColumns),
+			OnDelete:      foreignKeyActionFromChar(confDelType),
+			OnUpdate:      foreignKeyActionFromChar(confUpdType),
+			Deferrable:    deferrable,
+		})
+	}
+
+	return foreignKeys, rows.Err()
+}
+
+func introspectIndexes(db sqlQuerier, tableName string) ([]*types.Index, error) {
+	query := `select
+  ic.relname as index_name,
+  am.amname as method,
+  ix.indisunique,
+  ix.indisprimary,
+  array(select a.attname from pg_attribute a where a.attrelid = ix.indrelid and a.attnum = any(ix.indkey) order by array_position(ix.indkey, a.attnum)) as columns,
+  pg_get_expr(ix.indpred, ix.indrelid) as predicate,
+  pg_get_indexdef(ix.indexrelid) as definition
+from pg_index ix
+join pg_class ic on ic.oid = ix.indexrelid
+join pg_class tc on tc.oid = ix.indrelid
+join pg_namespace n on n.oid = tc.relnamespace
+join pg_am am on am.oid = ic.relam
+where tc.relname = $1
+  and n.nspname = $2
+  and not ix.indisprimary`
+
+	rows, err := db.Query(query, tableName, defaultSchemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for indexes")
+	}
+	defer rows.Close()
+
+	indexes := []*types.Index{}
+	for rows.Next() {
+		var name, method, definition string
+		var isUnique, isPrimary bool
+		var columns pq.StringArray
+		var predicate sql.NullString
+
+		if err := rows.Scan(&name, &method, &isUnique, &isPrimary, &columns, &predicate, &definition); err != nil {
+			return nil, errors.Wrap(err, "failed to scan index row")
+		}
+
+		index := &types.Index{
+			Name:     name,
+			Columns:  []string(columns),
+			IsUnique: isUnique,
+			Method:   method,
+		}
+		if predicate.Valid {
+			index.Predicate = &predicate.String
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
+
+// introspectConstraintNames lists the names of every constraint on
+// tableName, so buildIndexStatements can tell whether a given index is
+// backing a constraint (drop it with ALTER TABLE ... DROP CONSTRAINT) or is
+// standalone (drop it with DROP INDEX).
+func introspectConstraintNames(db sqlQuerier, tableName string) ([]string, error) {
+	query := `select con.conname
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+where c.relname = $1
+  and n.nspname = $2`
+
+	rows, err := db.Query(query, tableName, defaultSchemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for constraints")
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.Wrap(err, "failed to scan constraint name")
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// foreignKeyActionFromChar translates a pg_constraint confdeltype/confupdtype
+// code to the SQL action keyword it represents.
+func foreignKeyActionFromChar(action string) string {
+	switch action {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	default:
+		return "NO ACTION"
+	}
+}
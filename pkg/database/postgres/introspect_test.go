@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_foreignKeyActionFromChar(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		expected string
+	}{
+		{
+			name:     "restrict",
+			action:   "r",
+			expected: "RESTRICT",
+		},
+		{
+			name:     "cascade",
+			action:   "c",
+			expected: "CASCADE",
+		},
+		{
+			name:     "set null",
+			action:   "n",
+			expected: "SET NULL",
+		},
+		{
+			name:     "set default",
+			action:   "d",
+			expected: "SET DEFAULT",
+		},
+		{
+			name:     "no action",
+			action:   "a",
+			expected: "NO ACTION",
+		},
+		{
+			name:     "unrecognized code falls back to no action",
+			action:   "",
+			expected: "NO ACTION",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, foreignKeyActionFromChar(test.action))
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	schemasv1alpha4 "github.com/schemahero/schemahero/pkg/apis/schemas/v1alpha4"
+	"github.com/schemahero/schemahero/pkg/database/types"
+)
+
+// Statement is a single planned DDL statement, annotated with whether it can
+// be losslessly undone and, if not, why.
+type Statement struct {
+	SQL          string
+	Reversible   bool
+	DataLossRisk string // empty, or a short note such as "drops column data" or "narrows column, may truncate values"
+}
+
+var (
+	addColumnRegexp       = regexp.MustCompile(`(?i)add column\s+"?([a-zA-Z0-9_]+)"?`)
+	dropColumnRegexp      = regexp.MustCompile(`(?i)drop column\s+"?([a-zA-Z0-9_]+)"?`)
+	createIndexRegexp     = regexp.MustCompile(`(?i)create(?:\s+unique)?\s+index(?:\s+concurrently)?\s+"?([a-zA-Z0-9_]+)"?`)
+	dropIndexRegexp       = regexp.MustCompile(`(?i)drop index\s+"?([a-zA-Z0-9_]+)"?`)
+	addConstraintRegexp   = regexp.MustCompile(`(?i)add constraint\s+"?([a-zA-Z0-9_]+)"?`)
+	dropConstraintRegexp  = regexp.MustCompile(`(?i)drop constraint\s+"?([a-zA-Z0-9_]+)"?`)
+	alterColumnTypeRegexp = regexp.MustCompile(`(?i)alter column\s+"?([a-zA-Z0-9_]+)"?\s+type\s+([a-zA-Z][a-zA-Z0-9_ ]*?)(?:\s+using\b.*)?$`)
+	varcharLengthRegexp   = regexp.MustCompile(`(?i)^(?:character varying|varchar)\s*\(\s*(\d+)\s*\)$`)
+)
+
+// integerWidthRank and floatWidthRank order the common numeric families from
+// narrowest to widest, so a change within a family can be classified as a
+// widening (safe, reversible) or narrowing (lossy) without guessing at every
+// postgres type's storage size.
+var integerWidthRank = map[string]int{"smallint": 1, "integer": 2, "int": 2, "bigint": 3}
+var floatWidthRank = map[string]int{"real": 1, "float4": 1, "double precision": 2, "float8": 2}
+
+// PlanPostgresTableWithReverse plans tableName the same way PlanPostgresTable
+// does, but pairs every forward statement it can losslessly undo with the
+// statement that undoes it. Operators can stage the reverse plan as rollback
+// SQL before running the forward one. Every DROP COLUMN, and anything else
+// whose reverse can't be safely generated, is returned in forward with
+// Reversible=false and a DataLossRisk note instead of a guessed reverse
+// statement. Enum value removals never reach this planner: PlanPostgresType
+// refuses them with an error.
+func PlanPostgresTableWithReverse(uri string, tableName string, postgresTableSchema *schemasv1alpha4.SQLTableSchema) ([]Statement, []Statement, error) {
+	p, err := Connect(uri)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to connect to postgres")
+	}
+	defer p.db.Close()
+
+	graph, err := introspectTable(p.db, tableName)
+	if err != nil {
+		// introspection failed: plan against an empty graph, so no reverse
+		// statement is derived from the table's current shape
+		graph = &schemaGraph{}
+	}
+
+	forwardSQL, err := PlanPostgresTable(uri, tableName, postgresTableSchema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	forward := make([]Statement, 0, len(forwardSQL))
+	reverse := []Statement{}
+	for _, sql := range forwardSQL {
+		statement, reverseStatement := classifyStatement(tableName, sql, graph)
+		forward = append(forward, statement)
+		if reverseStatement != nil {
+			// undo in the opposite order the forward statements ran in
+			reverse = append([]Statement{*reverseStatement}, reverse...)
+		}
+	}
+
+	return forward, reverse, nil
+}
+
+func classifyStatement(tableName string, sql string, graph *schemaGraph) (Statement, *Statement) {
+	switch {
+	case strings.Contains(strings.ToLower(sql), "add column"):
+		if m := addColumnRegexp.FindStringSubmatch(sql); m != nil {
+			reverseSQL := fmt.Sprintf(`alter table %s drop column %s`, pq.QuoteIdentifier(tableName), pq.QuoteIdentifier(m[1]))
+			return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+		}
+
+	case strings.Contains(strings.ToLower(sql), "drop column"):
+		if m := dropColumnRegexp.FindStringSubmatch(sql); m != nil {
+			return Statement{SQL: sql, Reversible: false, DataLossRisk: fmt.Sprintf("drops column %q and any data in it", m[1])}, nil
+		}
+
+	case strings.Contains(strings.ToLower(sql), "create") && strings.Contains(strings.ToLower(sql), "index"):
+		if m := createIndexRegexp.FindStringSubmatch(sql); m != nil {
+			reverseSQL := fmt.Sprintf(`drop index %s`, pq.QuoteIdentifier(m[1]))
+			return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+		}
+
+	case strings.Contains(strings.ToLower(sql), "drop index"):
+		if m := dropIndexRegexp.FindStringSubmatch(sql); m != nil {
+			if reverseSQL, ok := recreateIndexStatement(tableName, m[1], graph); ok {
+				return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+			}
+		}
+
+	case strings.Contains(strings.ToLower(sql), "add constraint"):
+		if m := addConstraintRegexp.FindStringSubmatch(sql); m != nil {
+			reverseSQL := fmt.Sprintf(`alter table %s drop constraint %s`, pq.QuoteIdentifier(tableName), pq.QuoteIdentifier(m[1]))
+			return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+		}
+
+	case strings.Contains(strings.ToLower(sql), "drop constraint"):
+		if m := dropConstraintRegexp.FindStringSubmatch(sql); m != nil {
+			if reverseSQL, ok := recreateConstraintStatement(tableName, m[1], graph); ok {
+				return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+			}
+		}
+
+	case strings.Contains(strings.ToLower(sql), "alter column") && strings.Contains(strings.ToLower(sql), "type"):
+		if m := alterColumnTypeRegexp.FindStringSubmatch(sql); m != nil {
+			columnName, newType := m[1], strings.TrimSpace(m[2])
+			if oldType, ok := columnDataType(graph, columnName); ok && isWideningTypeChange(oldType, newType) {
+				reverseSQL := fmt.Sprintf(`alter table %s alter column %s type %s`, pq.QuoteIdentifier(tableName), pq.QuoteIdentifier(columnName), oldType)
+				return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+			}
+			return Statement{SQL: sql, Reversible: false, DataLossRisk: "narrows column, may truncate values"}, nil
+		}
+
+	case strings.Contains(strings.ToLower(sql), "create type") && strings.Contains(strings.ToLower(sql), "enum"):
+		// the type name is the only identifier between CREATE TYPE and AS ENUM
+		fields := strings.Fields(sql)
+		if len(fields) > 2 {
+			reverseSQL := fmt.Sprintf(`drop type %s`, fields[2])
+			return Statement{SQL: sql, Reversible: true}, &Statement{SQL: reverseSQL, Reversible: true}
+		}
+	}
+
+	return Statement{SQL: sql, Reversible: false, DataLossRisk: "no reverse statement could be derived"}, nil
+}
+
+// columnDataType looks up columnName's current type in graph, so
+// classifyStatement can compare it against the type a statement is changing
+// the column to.
+func columnDataType(graph *schemaGraph, columnName string) (string, bool) {
+	for _, column := range graph.Columns {
+		if column.Name == columnName {
+			return column.DataType, true
+		}
+	}
+	return "", false
+}
+
+// isWideningTypeChange reports whether moving a column from oldType to
+// newType is always safe: a wider integer or float family member, or a
+// longer (or unbounded text) varchar. Anything it doesn't recognize is
+// treated as a possible narrowing, since guessing safe would risk silently
+// truncating data.
+func isWideningTypeChange(oldType, newType string) bool {
+	oldType = strings.ToLower(strings.TrimSpace(oldType))
+	newType = strings.ToLower(strings.TrimSpace(newType))
+
+	if oldRank, ok := integerWidthRank[oldType]; ok {
+		if newRank, ok := integerWidthRank[newType]; ok {
+			return newRank > oldRank
+		}
+	}
+
+	if oldRank, ok := floatWidthRank[oldType]; ok {
+		if newRank, ok := floatWidthRank[newType]; ok {
+			return newRank > oldRank
+		}
+	}
+
+	if oldLen, ok := varcharLength(oldType); ok {
+		if newLen, ok := varcharLength(newType); ok {
+			return newLen > oldLen
+		}
+		if newType == "text" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func varcharLength(t string) (int, bool) {
+	m := varcharLengthRegexp.FindStringSubmatch(t)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func recreateIndexStatement(tableName string, indexName string, graph *schemaGraph) (string, bool) {
+	for _, index := range graph.Indexes {
+		if index.Name == indexName {
+			return AddIndexStatement(tableName, indexToSchemaIndex(index)), true
+		}
+	}
+	return "", false
+}
+
+func recreateConstraintStatement(tableName string, constraintName string, graph *schemaGraph) (string, bool) {
+	for _, foreignKey := range graph.ForeignKeys {
+		if foreignKey.Name == constraintName {
+			return AddForeignKeyStatement(tableName, foreignKeyToSchemaForeignKey(foreignKey)), true
+		}
+	}
+
+	if graph.PrimaryKey != nil && constraintName == fmt.Sprintf("%s_pkey", tableName) {
+		return AddConstrantStatement(tableName, graph.PrimaryKey), true
+	}
+
+	return "", false
+}
+
+// indexToSchemaIndex is the inverse of types.SchemaIndexToIndex: it turns an
+// introspected index back into the spec shape AddIndexStatement expects, so
+// a dropped index can be regenerated verbatim as its own reverse statement.
+func indexToSchemaIndex(index *types.Index) *schemasv1alpha4.SQLTableIndex {
+	return &schemasv1alpha4.SQLTableIndex{
+		Name:      index.Name,
+		Columns:   index.Columns,
+		IsUnique:  index.IsUnique,
+		Method:    index.Method,
+		Predicate: index.Predicate,
+	}
+}
+
+// foreignKeyToSchemaForeignKey is the inverse of
+// types.SchemaForeignKeyToForeignKey.
+func foreignKeyToSchemaForeignKey(foreignKey *types.ForeignKey) *schemasv1alpha4.SQLTableForeignKey {
+	return &schemasv1alpha4.SQLTableForeignKey{
+		Name:    foreignKey.Name,
+		Columns: foreignKey.ChildColumns,
+		References: schemasv1alpha4.SQLTableForeignKeyReferences{
+			Table:   foreignKey.ParentTable,
+			Columns: foreignKey.ParentColumns,
+		},
+	}
+}
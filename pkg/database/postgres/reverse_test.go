@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/schemahero/schemahero/pkg/database/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_varcharLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		dataType    string
+		expectedLen int
+		expectedOK  bool
+	}{
+		{
+			name:        "varchar",
+			dataType:    "varchar(255)",
+			expectedLen: 255,
+			expectedOK:  true,
+		},
+		{
+			name:        "character varying with spaces",
+			dataType:    "character varying (10)",
+			expectedLen: 10,
+			expectedOK:  true,
+		},
+		{
+			name:       "text is not bounded",
+			dataType:   "text",
+			expectedOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n, ok := varcharLength(test.dataType)
+			assert.Equal(t, test.expectedOK, ok)
+			if test.expectedOK {
+				assert.Equal(t, test.expectedLen, n)
+			}
+		})
+	}
+}
+
+func Test_isWideningTypeChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldType  string
+		newType  string
+		expected bool
+	}{
+		{
+			name:     "smallint to integer widens",
+			oldType:  "smallint",
+			newType:  "integer",
+			expected: true,
+		},
+		{
+			name:     "integer to smallint narrows",
+			oldType:  "integer",
+			newType:  "smallint",
+			expected: false,
+		},
+		{
+			name:     "integer to bigint widens",
+			oldType:  "integer",
+			newType:  "bigint",
+			expected: true,
+		},
+		{
+			name:     "real to double precision widens",
+			oldType:  "real",
+			newType:  "double precision",
+			expected: true,
+		},
+		{
+			name:     "varchar grows",
+			oldType:  "varchar(10)",
+			newType:  "varchar(20)",
+			expected: true,
+		},
+		{
+			name:     "varchar shrinks",
+			oldType:  "varchar(20)",
+			newType:  "varchar(10)",
+			expected: false,
+		},
+		{
+			name:     "varchar to text always widens",
+			oldType:  "varchar(20)",
+			newType:  "text",
+			expected: true,
+		},
+		{
+			name:     "unrecognized types are never assumed safe",
+			oldType:  "jsonb",
+			newType:  "json",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isWideningTypeChange(test.oldType, test.newType))
+		})
+	}
+}
+
+func Test_classifyStatement(t *testing.T) {
+	tableName := "t"
+	graph := &schemaGraph{
+		Columns: []*types.Column{
+			{Name: "age", DataType: "smallint"},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		sql                 string
+		expectedReversible  bool
+		expectedDataLoss    string
+		expectedReverseSQL  string
+		expectedHasReverse  bool
+	}{
+		{
+			name:               "add column",
+			sql:                `alter table "t" add column "c" integer`,
+			expectedReversible: true,
+			expectedHasReverse: true,
+			expectedReverseSQL: `alter table "t" drop column "c"`,
+		},
+		{
+			name:               "drop column is not reversible",
+			sql:                `alter table "t" drop column "c"`,
+			expectedReversible: false,
+			expectedDataLoss:   `drops column "c" and any data in it`,
+			expectedHasReverse: false,
+		},
+		{
+			name:               "widening alter column type is reversible",
+			sql:                `alter table "t" alter column "age" type integer`,
+			expectedReversible: true,
+			expectedHasReverse: true,
+			expectedReverseSQL: `alter table "t" alter column "age" type smallint`,
+		},
+		{
+			name:               "unrecognized alter column type change is not reversible",
+			sql:                `alter table "t" alter column "age" type text`,
+			expectedReversible: false,
+			expectedDataLoss:   "narrows column, may truncate values",
+			expectedHasReverse: false,
+		},
+		{
+			name:               "add check constraint is reversible",
+			sql:                `alter table "t" add constraint "chk" check ("age" > 0)`,
+			expectedReversible: true,
+			expectedHasReverse: true,
+			expectedReverseSQL: `alter table "t" drop constraint "chk"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statement, reverse := classifyStatement(tableName, test.sql, graph)
+
+			require.Equal(t, test.sql, statement.SQL)
+			assert.Equal(t, test.expectedReversible, statement.Reversible)
+			if test.expectedDataLoss != "" {
+				assert.Equal(t, test.expectedDataLoss, statement.DataLossRisk)
+			}
+
+			if test.expectedHasReverse {
+				require.NotNil(t, reverse)
+				assert.Equal(t, test.expectedReverseSQL, reverse.SQL)
+			} else {
+				assert.Nil(t, reverse)
+			}
+		})
+	}
+}
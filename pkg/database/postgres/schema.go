@@ -0,0 +1,561 @@
+package postgres
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/schemahero/schemahero/pkg/database/types"
+)
+
+// ReadSchema produces a complete, JSON-serializable snapshot of schemaName:
+// every table (columns, primary key, indexes, foreign keys, check
+// constraints), enum, sequence and view, each gathered in one round trip per
+// relation kind across the whole schema rather than one round trip per
+// relation kind per table. This is the basis for multi-table planning and
+// for tooling, such as `kubectl schemahero describe --output=json`, that
+// wants to diff two snapshots without a live database connection.
+func ReadSchema(uri string, schemaName string) (*types.Schema, error) {
+	p, err := Connect(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to postgres")
+	}
+	defer p.db.Close()
+
+	tableNames, err := readSchemaTableNames(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tables")
+	}
+
+	graphs, err := readSchemaGraphs(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect schema")
+	}
+
+	comments, err := introspectSchemaComments(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read table comments")
+	}
+
+	checkConstraints, err := introspectSchemaCheckConstraints(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read check constraints")
+	}
+
+	tables := []*types.SchemaTable{}
+	for _, tableName := range tableNames {
+		graph := graphs[tableName]
+		if graph == nil {
+			graph = &schemaGraph{}
+		}
+
+		tables = append(tables, &types.SchemaTable{
+			Name:             tableName,
+			Comment:          comments[tableName],
+			Columns:          graph.Columns,
+			PrimaryKey:       graph.PrimaryKey,
+			Indexes:          graph.Indexes,
+			ForeignKeys:      graph.ForeignKeys,
+			CheckConstraints: checkConstraints[tableName],
+		})
+	}
+
+	enums, err := readSchemaEnums(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read enums")
+	}
+
+	sequences, err := readSchemaSequences(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sequences")
+	}
+
+	views, err := readSchemaViews(p.db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read views")
+	}
+
+	return &types.Schema{
+		Name:      schemaName,
+		Tables:    tables,
+		Enums:     enums,
+		Sequences: sequences,
+		Views:     views,
+	}, nil
+}
+
+// readSchemaGraphs batches introspectTable's four pg_catalog queries across
+// every table in schemaName instead of running them once per table, keyed by
+// table name. ReadSchema uses it to build a whole-schema snapshot; a caller
+// planning several tables from that snapshot pulls each one's entry back out
+// with schemaGraphForTable instead of introspecting it again.
+func readSchemaGraphs(db sqlQuerier, schemaName string) (map[string]*schemaGraph, error) {
+	columns, err := introspectSchemaColumns(db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect columns")
+	}
+
+	primaryKeys, err := introspectSchemaPrimaryKeys(db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect primary keys")
+	}
+
+	foreignKeys, err := introspectSchemaForeignKeys(db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect foreign keys")
+	}
+
+	indexes, err := introspectSchemaIndexes(db, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to introspect indexes")
+	}
+
+	graphs := map[string]*schemaGraph{}
+	graphFor := func(tableName string) *schemaGraph {
+		graph, ok := graphs[tableName]
+		if !ok {
+			graph = &schemaGraph{}
+			graphs[tableName] = graph
+		}
+		return graph
+	}
+
+	for tableName, tableColumns := range columns {
+		graphFor(tableName).Columns = tableColumns
+	}
+	for tableName, primaryKey := range primaryKeys {
+		graphFor(tableName).PrimaryKey = primaryKey
+	}
+	for tableName, tableForeignKeys := range foreignKeys {
+		graphFor(tableName).ForeignKeys = tableForeignKeys
+	}
+	for tableName, tableIndexes := range indexes {
+		graphFor(tableName).Indexes = tableIndexes
+	}
+
+	return graphs, nil
+}
+
+// schemaGraphForTable pulls tableName's entry out of a schema-wide snapshot
+// and converts it to the same schemaGraph shape introspectTable produces, so
+// PlanPostgresTableFromSchema can reuse the same buildXStatements helpers a
+// fresh single-table introspection would.
+func schemaGraphForTable(schema *types.Schema, tableName string) *schemaGraph {
+	for _, table := range schema.Tables {
+		if table.Name == tableName {
+			return &schemaGraph{
+				Columns:     table.Columns,
+				PrimaryKey:  table.PrimaryKey,
+				ForeignKeys: table.ForeignKeys,
+				Indexes:     table.Indexes,
+			}
+		}
+	}
+	return &schemaGraph{}
+}
+
+func readSchemaTableNames(db sqlQuerier, schemaName string) ([]string, error) {
+	query := `select c.relname
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where n.nspname = $1
+  and c.relkind = 'r'
+order by c.relname`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tableNames := []string{}
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		tableNames = append(tableNames, tableName)
+	}
+
+	return tableNames, rows.Err()
+}
+
+func introspectSchemaColumns(db sqlQuerier, schemaName string) (map[string][]*types.Column, error) {
+	query := `select
+  c.relname,
+  a.attname,
+  pg_catalog.format_type(a.atttypid, a.atttypmod) as data_type,
+  a.attnotnull,
+  pg_get_expr(ad.adbin, ad.adrelid) as column_default,
+  nullif(a.attidentity, '') as identity,
+  a.attgenerated = 's' as is_generated,
+  t.typtype = 'e' as is_enum,
+  t.typname as udt_name,
+  col_description(a.attrelid, a.attnum) as comment,
+  exists (
+    select 1 from pg_index ix
+    where ix.indrelid = a.attrelid
+      and ix.indisunique
+      and ix.indkey = (a.attnum::text::int2vector)
+  ) as is_unique
+from pg_attribute a
+join pg_class c on c.oid = a.attrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_type t on t.oid = a.atttypid
+left join pg_attrdef ad on ad.adrelid = a.attrelid and ad.adnum = a.attnum
+where n.nspname = $1
+  and a.attnum > 0
+  and not a.attisdropped
+order by c.relname, a.attnum`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for columns")
+	}
+	defer rows.Close()
+
+	columns := map[string][]*types.Column{}
+	for rows.Next() {
+		var tableName, columnName, dataType, udtName string
+		var notNull, isGenerated, isEnum, isUnique bool
+		var columnDefault, identity, comment sql.NullString
+
+		if err := rows.Scan(&tableName, &columnName, &dataType, &notNull, &columnDefault, &identity, &isGenerated, &isEnum, &udtName, &comment, &isUnique); err != nil {
+			return nil, errors.Wrap(err, "failed to scan column row")
+		}
+
+		column := &types.Column{
+			Name:        columnName,
+			DataType:    dataType,
+			Constraints: &types.ColumnConstraints{},
+		}
+
+		if strings.HasSuffix(column.DataType, "[]") {
+			column.IsArray = true
+			column.DataType = strings.TrimSuffix(column.DataType, "[]")
+		}
+
+		if notNull {
+			column.Constraints.NotNull = &trueValue
+		} else {
+			column.Constraints.NotNull = &falseValue
+		}
+
+		if columnDefault.Valid {
+			value := stripOIDClass(columnDefault.String)
+			column.ColumnDefault = &value
+		}
+
+		if identity.Valid {
+			column.Identity = identity.String
+		}
+		column.GeneratedAlways = isGenerated
+
+		if isEnum {
+			enumName := udtName
+			column.EnumName = &enumName
+		}
+
+		if comment.Valid {
+			column.Comment = &comment.String
+		}
+		column.Unique = isUnique
+
+		columns[tableName] = append(columns[tableName], column)
+	}
+
+	return columns, rows.Err()
+}
+
+func introspectSchemaPrimaryKeys(db sqlQuerier, schemaName string) (map[string]*types.KeyConstraint, error) {
+	query := `select
+  c.relname,
+  a.attname
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_attribute a on a.attrelid = con.conrelid and a.attnum = any(con.conkey)
+where n.nspname = $1
+  and con.contype = 'p'
+order by c.relname, array_position(con.conkey, a.attnum)`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for primary keys")
+	}
+	defer rows.Close()
+
+	columnsByTable := map[string][]string{}
+	order := []string{}
+	for rows.Next() {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
+			return nil, errors.Wrap(err, "failed to scan primary key column")
+		}
+		if _, ok := columnsByTable[tableName]; !ok {
+			order = append(order, tableName)
+		}
+		columnsByTable[tableName] = append(columnsByTable[tableName], columnName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	primaryKeys := map[string]*types.KeyConstraint{}
+	for _, tableName := range order {
+		primaryKeys[tableName] = &types.KeyConstraint{
+			IsPrimary: true,
+			Columns:   columnsByTable[tableName],
+		}
+	}
+
+	return primaryKeys, nil
+}
+
+func introspectSchemaForeignKeys(db sqlQuerier, schemaName string) (map[string][]*types.ForeignKey, error) {
+	query := `select
+  c.relname,
+  con.conname,
+  array(select a.attname from pg_attribute a where a.attrelid = con.conrelid and a.attnum = any(con.conkey) order by array_position(con.conkey, a.attnum)) as child_columns,
+  fc.relname as parent_table,
+  array(select a.attname from pg_attribute a where a.attrelid = con.confrelid and a.attnum = any(con.confkey) order by array_position(con.confkey, a.attnum)) as parent_columns,
+  con.confupdtype,
+  con.confdeltype,
+  con.condeferrable
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+join pg_class fc on fc.oid = con.confrelid
+where n.nspname = $1
+  and con.contype = 'f'`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for foreign keys")
+	}
+	defer rows.Close()
+
+	foreignKeys := map[string][]*types.ForeignKey{}
+	for rows.Next() {
+		var tableName, name, parentTable string
+		var childColumns, parentColumns pq.StringArray
+		var confUpdType, confDelType string
+		var deferrable bool
+
+		if err := rows.Scan(&tableName, &name, &childColumns, &parentTable, &parentColumns, &confUpdType, &confDelType, &deferrable); err != nil {
+			return nil, errors.Wrap(err, "failed to scan foreign key row")
+		}
+
+		foreignKeys[tableName] = append(foreignKeys[tableName], &types.ForeignKey{
+			Name:          name,
+			ChildColumns:  []string(childColumns),
+			ParentTable:   parentTable,
+			ParentColumns: []string(parentColumns),
+			OnDelete:      foreignKeyActionFromChar(confDelType),
+			OnUpdate:      foreignKeyActionFromChar(confUpdType),
+			Deferrable:    deferrable,
+		})
+	}
+
+	return foreignKeys, rows.Err()
+}
+
+func introspectSchemaIndexes(db sqlQuerier, schemaName string) (map[string][]*types.Index, error) {
+	query := `select
+  tc.relname as table_name,
+  ic.relname as index_name,
+  am.amname as method,
+  ix.indisunique,
+  array(select a.attname from pg_attribute a where a.attrelid = ix.indrelid and a.attnum = any(ix.indkey) order by array_position(ix.indkey, a.attnum)) as columns,
+  pg_get_expr(ix.indpred, ix.indrelid) as predicate
+from pg_index ix
+join pg_class ic on ic.oid = ix.indexrelid
+join pg_class tc on tc.oid = ix.indrelid
+join pg_namespace n on n.oid = tc.relnamespace
+join pg_am am on am.oid = ic.relam
+where n.nspname = $1
+  and not ix.indisprimary`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query pg_catalog for indexes")
+	}
+	defer rows.Close()
+
+	indexes := map[string][]*types.Index{}
+	for rows.Next() {
+		var tableName, name, method string
+		var isUnique bool
+		var columns pq.StringArray
+		var predicate sql.NullString
+
+		if err := rows.Scan(&tableName, &name, &method, &isUnique, &columns, &predicate); err != nil {
+			return nil, errors.Wrap(err, "failed to scan index row")
+		}
+
+		index := &types.Index{
+			Name:     name,
+			Columns:  []string(columns),
+			IsUnique: isUnique,
+			Method:   method,
+		}
+		if predicate.Valid {
+			index.Predicate = &predicate.String
+		}
+
+		indexes[tableName] = append(indexes[tableName], index)
+	}
+
+	return indexes, rows.Err()
+}
+
+func introspectSchemaComments(db sqlQuerier, schemaName string) (map[string]*string, error) {
+	query := `select
+  c.relname,
+  obj_description(c.oid, 'pg_class')
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where n.nspname = $1
+  and c.relkind = 'r'`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := map[string]*string{}
+	for rows.Next() {
+		var tableName string
+		var comment *string
+		if err := rows.Scan(&tableName, &comment); err != nil {
+			return nil, err
+		}
+		comments[tableName] = comment
+	}
+
+	return comments, rows.Err()
+}
+
+func introspectSchemaCheckConstraints(db sqlQuerier, schemaName string) (map[string][]*types.CheckConstraint, error) {
+	query := `select
+  c.relname,
+  con.conname,
+  pg_get_constraintdef(con.oid)
+from pg_constraint con
+join pg_class c on c.oid = con.conrelid
+join pg_namespace n on n.oid = c.relnamespace
+where n.nspname = $1
+  and con.contype = 'c'`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkConstraints := map[string][]*types.CheckConstraint{}
+	for rows.Next() {
+		var tableName string
+		checkConstraint := &types.CheckConstraint{}
+		if err := rows.Scan(&tableName, &checkConstraint.Name, &checkConstraint.Expression); err != nil {
+			return nil, err
+		}
+		checkConstraints[tableName] = append(checkConstraints[tableName], checkConstraint)
+	}
+
+	return checkConstraints, rows.Err()
+}
+
+func readSchemaEnums(db sqlQuerier, schemaName string) ([]*types.Enum, error) {
+	query := `select
+  t.typname,
+  array(select e.enumlabel from pg_enum e where e.enumtypid = t.oid order by e.enumsortorder)
+from pg_type t
+join pg_namespace n on n.oid = t.typnamespace
+where n.nspname = $1
+  and t.typtype = 'e'
+order by t.typname`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enums := []*types.Enum{}
+	for rows.Next() {
+		var name string
+		var values pq.StringArray
+		if err := rows.Scan(&name, &values); err != nil {
+			return nil, err
+		}
+		enums = append(enums, &types.Enum{
+			Name:   name,
+			Values: []string(values),
+		})
+	}
+
+	return enums, rows.Err()
+}
+
+func readSchemaSequences(db sqlQuerier, schemaName string) ([]*types.Sequence, error) {
+	query := `select
+  c.relname,
+  s.seqstart,
+  s.seqincrement,
+  s.seqmin,
+  s.seqmax,
+  s.seqcycle
+from pg_sequence s
+join pg_class c on c.oid = s.seqrelid
+join pg_namespace n on n.oid = c.relnamespace
+where n.nspname = $1
+order by c.relname`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sequences := []*types.Sequence{}
+	for rows.Next() {
+		sequence := &types.Sequence{}
+		if err := rows.Scan(&sequence.Name, &sequence.Start, &sequence.Increment, &sequence.Min, &sequence.Max, &sequence.Cycle); err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, sequence)
+	}
+
+	return sequences, rows.Err()
+}
+
+func readSchemaViews(db sqlQuerier, schemaName string) ([]*types.View, error) {
+	query := `select
+  c.relname,
+  pg_get_viewdef(c.oid)
+from pg_class c
+join pg_namespace n on n.oid = c.relnamespace
+where n.nspname = $1
+  and c.relkind in ('v', 'm')
+order by c.relname`
+
+	rows, err := db.Query(query, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := []*types.View{}
+	for rows.Next() {
+		view := &types.View{}
+		if err := rows.Scan(&view.Name, &view.Definition); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+
+	return views, rows.Err()
+}
@@ -0,0 +1,58 @@
+package types
+
+// Schema is a complete, JSON-serializable snapshot of every relation in a
+// database schema: tables, enums, sequences and views. It is produced by
+// postgres.ReadSchema in a handful of round trips and lets tooling diff two
+// points in time, or generate CRDs, without holding a live database
+// connection.
+type Schema struct {
+	Name      string         `json:"name"`
+	Tables    []*SchemaTable `json:"tables"`
+	Enums     []*Enum        `json:"enums,omitempty"`
+	Sequences []*Sequence    `json:"sequences,omitempty"`
+	Views     []*View        `json:"views,omitempty"`
+}
+
+// SchemaTable is a table as it appears in a Schema snapshot. It carries
+// everything the per-table planner used to have to re-derive with its own
+// information_schema queries.
+type SchemaTable struct {
+	Name             string             `json:"name"`
+	Comment          *string            `json:"comment,omitempty"`
+	Columns          []*Column          `json:"columns"`
+	PrimaryKey       *KeyConstraint     `json:"primaryKey,omitempty"`
+	Indexes          []*Index           `json:"indexes,omitempty"`
+	ForeignKeys      []*ForeignKey      `json:"foreignKeys,omitempty"`
+	CheckConstraints []*CheckConstraint `json:"checkConstraints,omitempty"`
+}
+
+// CheckConstraint is a CHECK constraint as reported by pg_get_constraintdef,
+// e.g. "CHECK ((price > (0)::numeric))".
+type CheckConstraint struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// Enum is a user-defined CREATE TYPE ... AS ENUM, in declaration order.
+type Enum struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Sequence is a standalone sequence, or the implicit sequence backing a
+// serial/identity column.
+type Sequence struct {
+	Name      string `json:"name"`
+	Start     int64  `json:"start"`
+	Increment int64  `json:"increment"`
+	Min       int64  `json:"min"`
+	Max       int64  `json:"max"`
+	Cycle     bool   `json:"cycle"`
+}
+
+// View is a plain or materialized view, captured by its canonical
+// pg_get_viewdef SELECT.
+type View struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
@@ -0,0 +1,28 @@
+package generate
+
+import (
+	"github.com/pkg/errors"
+	"github.com/schemahero/schemahero/pkg/database/types"
+)
+
+// GeneratePostgresTableCRFromSchema renders a Table custom resource for a
+// single node of a types.Schema snapshot (as produced by
+// postgres.ReadSchema), without needing a live connection back to the
+// database it was read from.
+func GeneratePostgresTableCRFromSchema(databaseName string, schemaTable *types.SchemaTable) (string, error) {
+	var primaryKey []string
+	if schemaTable.PrimaryKey != nil {
+		primaryKey = schemaTable.PrimaryKey.Columns
+	}
+
+	table := &types.Table{
+		Name: schemaTable.Name,
+	}
+
+	yaml, err := generatePostgresqlTableYAML(databaseName, table, primaryKey, schemaTable.ForeignKeys, schemaTable.Indexes, schemaTable.Columns)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate postgres table yaml")
+	}
+
+	return yaml, nil
+}